@@ -584,6 +584,16 @@ impl StreamType {
             "sub"
         }
     }
+
+    // Name of the HLS muxer for this stream of the monitor.
+    #[must_use]
+    pub fn hls_name(&self, monitor_id: &MonitorId) -> String {
+        if self.is_main() {
+            monitor_id.to_string()
+        } else {
+            monitor_id.to_string() + "_sub"
+        }
+    }
 }
 
 #[derive(Clone, Debug, Default)]
@@ -788,6 +798,88 @@ pub struct TrackParameters {
     pub extra_data: Vec<u8>,
 }
 
+impl TrackParameters {
+    // Parses the AVC decoder configuration record in `extra_data`.
+    pub fn info(&self) -> Result<TrackInfo, ParseAvccError> {
+        use ParseAvccError::*;
+        let header = self.extra_data.get(..6).ok_or(UnexpectedEof)?;
+        if header[0] != 1 {
+            return Err(Version(header[0]));
+        }
+        let profile = header[1];
+        let level = header[3];
+        let num_sps = header[5] & 0x1f;
+
+        let mut pos = 6;
+        let sps = read_parameter_sets(&self.extra_data, &mut pos, num_sps)?;
+
+        let num_pps = *self.extra_data.get(pos).ok_or(UnexpectedEof)?;
+        pos += 1;
+        let pps = read_parameter_sets(&self.extra_data, &mut pos, num_pps)?;
+
+        Ok(TrackInfo {
+            codec: self.codec.clone(),
+            width: self.width,
+            height: self.height,
+            profile,
+            level,
+            sps,
+            pps,
+        })
+    }
+}
+
+// Reads `count` length prefixed parameter sets and returns them hex encoded.
+fn read_parameter_sets(
+    data: &[u8],
+    pos: &mut usize,
+    count: u8,
+) -> Result<Vec<String>, ParseAvccError> {
+    use std::fmt::Write;
+    let mut sets = Vec::new();
+    for _ in 0..count {
+        let len = data
+            .get(*pos..*pos + 2)
+            .ok_or(ParseAvccError::UnexpectedEof)?;
+        let len = usize::from(u16::from_be_bytes([len[0], len[1]]));
+        *pos += 2;
+
+        let set = data
+            .get(*pos..*pos + len)
+            .ok_or(ParseAvccError::UnexpectedEof)?;
+        *pos += len;
+
+        sets.push(set.iter().fold(String::new(), |mut s, b| {
+            _ = write!(s, "{b:02x}");
+            s
+        }));
+    }
+    Ok(sets)
+}
+
+// Machine readable codec details of a video track.
+#[derive(Debug, Serialize, PartialEq, Eq)]
+pub struct TrackInfo {
+    pub codec: String,
+    pub width: u16,
+    pub height: u16,
+    pub profile: u8,
+    pub level: u8,
+
+    // Hex encoded parameter sets.
+    pub sps: Vec<String>,
+    pub pps: Vec<String>,
+}
+
+#[derive(Debug, Error, PartialEq, Eq)]
+pub enum ParseAvccError {
+    #[error("unexpected end of data")]
+    UnexpectedEof,
+
+    #[error("unsupported configuration version: {0}")]
+    Version(u8),
+}
+
 #[derive(Clone, Debug, Default)]
 pub struct H264Data {
     pub pts: UnixH264,         // Absolute presentation timestamp.
@@ -822,3 +914,56 @@ impl MsgLogger for DummyMsgLogger {
 pub fn new_dummy_msg_logger() -> Arc<impl MsgLogger> {
     Arc::new(DummyMsgLogger {})
 }
+
+#[allow(clippy::unwrap_used)]
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pretty_assertions::assert_eq;
+    use test_case::test_case;
+
+    fn test_params(extra_data: Vec<u8>) -> TrackParameters {
+        TrackParameters {
+            width: 1920,
+            height: 1080,
+            codec: "avc1.64001f".to_owned(),
+            extra_data,
+        }
+    }
+
+    #[test]
+    fn test_track_info() {
+        let params = test_params(vec![
+            1,    // Configuration version.
+            0x64, // Profile.
+            0,    // Profile compatibility.
+            0x1f, // Level.
+            0xff, // Reserved, Length size minus one.
+            0xe1, // Reserved, N sequence parameters.
+            0, 4, // Length.
+            0x67, 0x64, 0, 0x1f, // SPS.
+            1,    // N picture parameters.
+            0, 2, // Length.
+            0x68, 0xee, // PPS.
+        ]);
+
+        let want = TrackInfo {
+            codec: "avc1.64001f".to_owned(),
+            width: 1920,
+            height: 1080,
+            profile: 0x64,
+            level: 0x1f,
+            sps: vec!["6764001f".to_owned()],
+            pps: vec!["68ee".to_owned()],
+        };
+        assert_eq!(want, params.info().unwrap());
+    }
+
+    #[test_case(Vec::new(), ParseAvccError::UnexpectedEof; "empty")]
+    #[test_case(vec![0, 0x64, 0, 0x1f, 0xff, 0xe0, 0], ParseAvccError::Version(0); "version")]
+    #[test_case(vec![1, 0x64, 0, 0x1f, 0xff, 0xe1, 0, 4, 0x67], ParseAvccError::UnexpectedEof; "short sps")]
+    #[test_case(vec![1, 0x64, 0, 0x1f, 0xff, 0xe0], ParseAvccError::UnexpectedEof; "no pps count")]
+    fn test_track_info_error(extra_data: Vec<u8>, want: ParseAvccError) {
+        assert_eq!(want, test_params(extra_data).info().unwrap_err());
+    }
+}
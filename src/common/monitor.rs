@@ -93,6 +93,12 @@ impl MonitorConfig {
         Duration::from_f64(self.config.video_length * (MINUTE as f64))
     }
 
+    // End recordings at the first segment boundary after local midnight.
+    #[must_use]
+    pub fn split_at_midnight(&self) -> bool {
+        self.config.split_at_midnight
+    }
+
     /*
         // TimestampOffset returns the timestamp offset.
         func (c Config) TimestampOffset() string {
@@ -118,6 +124,9 @@ pub struct Config {
 
     #[serde(rename = "videoLength")]
     pub video_length: f64,
+
+    #[serde(default, rename = "splitAtMidnight")]
+    pub split_at_midnight: bool,
 }
 
 impl Serialize for MonitorConfig {
@@ -188,6 +197,46 @@ pub struct SourceRtspConfig {
 
     #[serde(rename = "subStream")]
     pub sub_stream: Option<RtspUrl>,
+
+    // Seconds without frames before the stream is considered stalled.
+    #[serde(default, rename = "stallTimeout")]
+    pub stall_timeout: u32,
+
+    // Minimum resolution of the main stream.
+    #[serde(default, rename = "minWidth")]
+    pub min_width: u16,
+
+    #[serde(default, rename = "minHeight")]
+    pub min_height: u16,
+
+    #[serde(default, rename = "minResolutionPolicy")]
+    pub min_resolution_policy: MinResolutionPolicy,
+}
+
+impl SourceRtspConfig {
+    // Returns None if stall detection is disabled.
+    #[must_use]
+    pub fn stall_timeout(&self) -> Option<std::time::Duration> {
+        if self.stall_timeout == 0 {
+            return None;
+        }
+        Some(std::time::Duration::from_secs(u64::from(
+            self.stall_timeout,
+        )))
+    }
+}
+
+// Action taken when the main stream is below the minimum resolution.
+#[derive(Clone, Copy, Debug, Default, Deserialize, PartialEq, Eq)]
+pub enum MinResolutionPolicy {
+    // Log a warning and keep streaming.
+    #[serde(rename = "warn")]
+    Warn,
+
+    // Stop the source until the monitor is restarted.
+    #[default]
+    #[serde(rename = "reject")]
+    Reject,
 }
 
 #[derive(Clone, Debug, Deserialize, PartialEq, Eq)]
@@ -248,3 +297,51 @@ impl Deref for RtspUrl {
         &self.0
     }
 }
+
+#[allow(clippy::unwrap_used)]
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pretty_assertions::assert_eq;
+    use serde_json::json;
+
+    #[test]
+    fn test_source_rtsp_stall_timeout() {
+        let parse = |v| serde_json::from_value::<SourceRtspConfig>(v).unwrap();
+
+        let config = parse(json!({"protocol": "tcp", "mainStream": "rtsp://x"}));
+        assert_eq!(None, config.stall_timeout());
+
+        let config = parse(json!({
+            "protocol": "tcp",
+            "mainStream": "rtsp://x",
+            "stallTimeout": 3,
+        }));
+        assert_eq!(
+            Some(std::time::Duration::from_secs(3)),
+            config.stall_timeout()
+        );
+
+        let config = parse(json!({
+            "protocol": "tcp",
+            "mainStream": "rtsp://x",
+            "stallTimeout": 0,
+        }));
+        assert_eq!(None, config.stall_timeout());
+    }
+
+    #[test]
+    fn test_source_rtsp_min_resolution_policy() {
+        let parse = |v| serde_json::from_value::<SourceRtspConfig>(v).unwrap();
+
+        let config = parse(json!({"protocol": "tcp", "mainStream": "rtsp://x"}));
+        assert_eq!(MinResolutionPolicy::Reject, config.min_resolution_policy);
+
+        let config = parse(json!({
+            "protocol": "tcp",
+            "mainStream": "rtsp://x",
+            "minResolutionPolicy": "warn",
+        }));
+        assert_eq!(MinResolutionPolicy::Warn, config.min_resolution_policy);
+    }
+}
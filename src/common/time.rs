@@ -1,6 +1,6 @@
 // SPDX-License-Identifier: GPL-2.0-or-later
 
-use chrono::NaiveDateTime;
+use chrono::{NaiveDateTime, TimeZone};
 use serde::{Deserialize, Serialize};
 use std::{
     fmt::Display,
@@ -80,6 +80,27 @@ impl UnixNano {
         let nanosec = self.0 % SECOND;
         NaiveDateTime::from_timestamp_opt(sec, nanosec as u32)
     }
+
+    // Returns the first midnight after `self` in the time zone `tz`. If a DST
+    // transition skips midnight, the first valid time of that day is returned.
+    #[must_use]
+    pub fn next_midnight<Tz: TimeZone>(&self, tz: &Tz) -> Option<Self> {
+        let local = tz.from_utc_datetime(&self.as_chrono()?);
+        let mut midnight = local.date_naive().succ_opt()?.and_hms_opt(0, 0, 0)?;
+        for _ in 0..24 * 4 {
+            if let Some(v) = tz.from_local_datetime(&midnight).earliest() {
+                return Some(Self(v.timestamp_nanos_opt()?));
+            }
+            midnight = midnight.checked_add_signed(chrono::Duration::minutes(15))?;
+        }
+        None
+    }
+
+    // Returns the first midnight after `self` in the local time zone.
+    #[must_use]
+    pub fn next_local_midnight(&self) -> Option<Self> {
+        self.next_midnight(&chrono::Local)
+    }
 }
 
 impl From<Duration> for UnixNano {
@@ -421,6 +442,7 @@ pub fn nano_to_timescale(value: i64, timescale: i64) -> i64 {
     (secs * timescale) + (dec * timescale / SECOND)
 }
 
+#[allow(clippy::unwrap_used)]
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -436,4 +458,89 @@ mod tests {
     fn test_nano_to_timescale(input: i64, scale: i64, want: i64) {
         assert_eq!(want, nano_to_timescale(input, scale));
     }
+
+    #[test_case(0, 0, 24 * HOUR; "utc start of day")]
+    #[test_case(23 * HOUR, 0, 24 * HOUR; "utc end of day")]
+    #[test_case(24 * HOUR, 0, 48 * HOUR; "utc midnight")]
+    #[test_case(0, 2 * 3600, 22 * HOUR; "east")]
+    #[test_case(23 * HOUR, -3600, 25 * HOUR; "west")]
+    fn test_next_midnight(input: i64, offset_secs: i32, want: i64) {
+        let tz = chrono::FixedOffset::east_opt(offset_secs).unwrap();
+        assert_eq!(
+            Some(UnixNano::new(want)),
+            UnixNano::new(input).next_midnight(&tz)
+        );
+    }
+
+    // Springs forward from UTC+0 to UTC+1 at 23:30 UTC on
+    // 1970-01-01, the local times 23:30 to 00:30 do not exist.
+    #[derive(Clone, Copy, Debug)]
+    struct SkipMidnight;
+
+    impl SkipMidnight {
+        fn transition() -> NaiveDateTime {
+            chrono::NaiveDate::from_ymd_opt(1970, 1, 1)
+                .unwrap()
+                .and_hms_opt(23, 30, 0)
+                .unwrap()
+        }
+
+        fn before() -> chrono::FixedOffset {
+            chrono::FixedOffset::east_opt(0).unwrap()
+        }
+
+        fn after() -> chrono::FixedOffset {
+            chrono::FixedOffset::east_opt(3600).unwrap()
+        }
+    }
+
+    impl TimeZone for SkipMidnight {
+        type Offset = chrono::FixedOffset;
+
+        fn from_offset(_: &Self::Offset) -> Self {
+            Self
+        }
+
+        fn offset_from_local_date(
+            &self,
+            local: &chrono::NaiveDate,
+        ) -> chrono::LocalResult<Self::Offset> {
+            self.offset_from_local_datetime(&local.and_hms_opt(0, 0, 0).unwrap())
+        }
+
+        fn offset_from_local_datetime(
+            &self,
+            local: &NaiveDateTime,
+        ) -> chrono::LocalResult<Self::Offset> {
+            let before = *local - Self::before() < Self::transition();
+            let after = *local - Self::after() >= Self::transition();
+            match (before, after) {
+                (true, true) => chrono::LocalResult::Ambiguous(Self::before(), Self::after()),
+                (true, false) => chrono::LocalResult::Single(Self::before()),
+                (false, true) => chrono::LocalResult::Single(Self::after()),
+                (false, false) => chrono::LocalResult::None,
+            }
+        }
+
+        fn offset_from_utc_date(&self, utc: &chrono::NaiveDate) -> Self::Offset {
+            self.offset_from_utc_datetime(&utc.and_hms_opt(0, 0, 0).unwrap())
+        }
+
+        fn offset_from_utc_datetime(&self, utc: &NaiveDateTime) -> Self::Offset {
+            if *utc < Self::transition() {
+                Self::before()
+            } else {
+                Self::after()
+            }
+        }
+    }
+
+    #[test]
+    fn test_next_midnight_skipped() {
+        // Local 00:30 is the first valid time of the new day.
+        assert_eq!(
+            Some(UnixNano::new(23 * HOUR + 30 * MINUTE)),
+            UnixNano::new(0).next_midnight(&SkipMidnight)
+        );
+    }
 }
@@ -15,8 +15,8 @@ use axum::{
 use common::{
     monitor::{MonitorConfig, MonitorConfigs},
     recording::RecordingId,
-    AccountSetRequest, AccountsMap, AuthAccountDeleteError, DynAuth, DynLogger, ILogger, LogEntry,
-    LogLevel, MonitorId,
+    AccountSetRequest, AccountsMap, AuthAccountDeleteError, DynAuth, DynLogger, HlsMuxer as _,
+    ILogger, LogEntry, LogLevel, MonitorId, StreamType,
 };
 use hls::{HlsQuery, HlsServer};
 use http::{HeaderValue, Request};
@@ -436,6 +436,65 @@ pub async fn monitor_restart_handler(
     StatusCode::OK.into_response()
 }
 
+pub async fn recording_pause_handler(State(monitor_manager): State<MonitorManager>) -> Response {
+    monitor_manager.set_recording_all(false).await;
+    StatusCode::OK.into_response()
+}
+
+pub async fn recording_resume_handler(State(monitor_manager): State<MonitorManager>) -> Response {
+    monitor_manager.set_recording_all(true).await;
+    StatusCode::OK.into_response()
+}
+
+#[derive(Debug, Deserialize)]
+pub struct MonitorStreamQuery {
+    id: MonitorId,
+
+    #[serde(default, rename = "sub-stream")]
+    sub_stream: bool,
+}
+
+pub async fn monitor_params_handler(
+    State(hls_server): State<Arc<HlsServer>>,
+    query: Query<MonitorStreamQuery>,
+) -> Response {
+    let stream_type = if query.sub_stream {
+        StreamType::Sub
+    } else {
+        StreamType::Main
+    };
+    let name = stream_type.hls_name(&query.id);
+    let Some(Some(muxer)) = hls_server.muxer_by_name(name).await else {
+        return (StatusCode::NOT_FOUND, "stream is not running").into_response();
+    };
+    match muxer.params().info() {
+        Ok(v) => Json(v).into_response(),
+        Err(e) => (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            format!("parse params: {e}"),
+        )
+            .into_response(),
+    }
+}
+
+pub async fn monitor_status_handler(
+    State(monitor_manager): State<MonitorManager>,
+    query: Query<MonitorStreamQuery>,
+) -> Response {
+    let stream_type = if query.sub_stream {
+        StreamType::Sub
+    } else {
+        StreamType::Main
+    };
+    let Some(info) = monitor_manager
+        .monitor_source_info(query.id.clone(), stream_type)
+        .await
+    else {
+        return (StatusCode::NOT_FOUND, "stream is not running").into_response();
+    };
+    Json(info).into_response()
+}
+
 pub async fn monitors_handler(
     State(monitor_manager): State<MonitorManager>,
 ) -> Json<MonitorConfigs> {
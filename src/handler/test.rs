@@ -2,15 +2,22 @@
 
 #![allow(clippy::unwrap_used)]
 
-use crate::asset_handler;
+use crate::{asset_handler, monitor_params_handler, monitor_status_handler, MonitorStreamQuery};
 use axum::{
     body::to_bytes,
-    extract::{Path, State},
+    extract::{Path, Query, State},
     response::IntoResponse,
 };
+use bytesize::ByteSize;
+use common::{DummyLogger, H264Data, MonitorId, TrackParameters};
+use hls::HlsServer;
 use http::{header, StatusCode};
+use monitor::MonitorManager;
 use pretty_assertions::assert_eq;
-use std::{borrow::Cow, collections::HashMap};
+use recdb::{Disk, RecDb};
+use std::{borrow::Cow, collections::HashMap, sync::Arc};
+use tempfile::TempDir;
+use tokio_util::sync::CancellationToken;
 
 #[tokio::test]
 async fn handle_assets_ok() {
@@ -45,3 +52,83 @@ async fn handle_assets_404() {
         to_bytes(response.into_body(), usize::MAX).await.unwrap()
     );
 }
+
+fn stream_query(id: &str, sub_stream: bool) -> Query<MonitorStreamQuery> {
+    Query(MonitorStreamQuery {
+        id: MonitorId::try_from(id.to_owned()).unwrap(),
+        sub_stream,
+    })
+}
+
+#[tokio::test]
+async fn handle_monitor_params_ok() {
+    let token = CancellationToken::new();
+    let server = Arc::new(HlsServer::new(token.clone(), DummyLogger::new()));
+
+    let params = TrackParameters {
+        width: 64,
+        height: 64,
+        codec: "avc1.64001f".to_owned(),
+        extra_data: vec![
+            1, 0x64, 0, 0x1f, 0xff, 0xe1, 0, 4, 0x67, 0x64, 0, 0x1f, 1, 0, 2, 0x68, 0xee,
+        ],
+    };
+    let first_sample = H264Data {
+        random_access_present: true,
+        ..Default::default()
+    };
+    server
+        .new_muxer(token.clone(), "a_sub".to_owned(), params, first_sample)
+        .await
+        .unwrap()
+        .unwrap();
+
+    let response = monitor_params_handler(State(server), stream_query("a", true))
+        .await
+        .into_response();
+
+    assert_eq!(StatusCode::OK, response.status());
+    let body = to_bytes(response.into_body(), usize::MAX).await.unwrap();
+    let want = r#"{"codec":"avc1.64001f","width":64,"height":64,"profile":100,"level":31,"sps":["6764001f"],"pps":["68ee"]}"#;
+    assert_eq!(want, body);
+    token.cancel();
+}
+
+#[tokio::test]
+async fn handle_monitor_params_404() {
+    let token = CancellationToken::new();
+    let server = Arc::new(HlsServer::new(token.clone(), DummyLogger::new()));
+
+    let response = monitor_params_handler(State(server), stream_query("a", false))
+        .await
+        .into_response();
+
+    assert_eq!(StatusCode::NOT_FOUND, response.status());
+    token.cancel();
+}
+
+#[tokio::test]
+async fn handle_monitor_status_404() {
+    let temp_dir = TempDir::new().unwrap();
+    let token = CancellationToken::new();
+    let disk = Disk::new(temp_dir.path().to_path_buf(), ByteSize(0));
+    let rec_db = RecDb::new(DummyLogger::new(), temp_dir.path().to_path_buf(), disk);
+    let manager = MonitorManager::new(
+        temp_dir.path().join("monitors"),
+        Arc::new(rec_db),
+        DummyLogger::new(),
+        Arc::new(HlsServer::new(token.clone(), DummyLogger::new())),
+    )
+    .unwrap();
+
+    let response = monitor_status_handler(State(manager), stream_query("a", false))
+        .await
+        .into_response();
+
+    assert_eq!(StatusCode::NOT_FOUND, response.status());
+    assert_eq!(
+        "stream is not running",
+        to_bytes(response.into_body(), usize::MAX).await.unwrap()
+    );
+    token.cancel();
+}
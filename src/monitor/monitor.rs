@@ -4,7 +4,9 @@ mod recorder;
 mod source;
 
 use recdb::RecDb;
-pub use source::{DecoderError, Source, SubscribeDecodedError};
+pub use source::{
+    BreakerState, DecoderError, Source, SourceInfo, SourceStatus, SubscribeDecodedError,
+};
 
 use crate::{recorder::new_recorder, source::SourceRtsp};
 use async_trait::async_trait;
@@ -23,7 +25,7 @@ use std::{
 use thiserror::Error;
 use tokio::{
     io::AsyncWriteExt,
-    sync::{mpsc, oneshot, Mutex},
+    sync::{mpsc, oneshot, watch, Mutex},
 };
 use tokio_util::sync::CancellationToken;
 
@@ -34,6 +36,8 @@ pub struct Monitor {
     shutdown_complete: Mutex<mpsc::Receiver<()>>,
     source_main_tx: mpsc::Sender<oneshot::Sender<Arc<Source>>>,
     source_sub_tx: mpsc::Sender<oneshot::Sender<Option<Arc<Source>>>>,
+    source_main_status: SourceStatus,
+    source_sub_status: Option<SourceStatus>,
     send_event_tx: mpsc::Sender<Event>,
 }
 
@@ -42,6 +46,15 @@ impl Monitor {
         &self.config
     }
 
+    // Returns None if the monitor doesn't have a sub stream.
+    #[must_use]
+    pub fn source_info(&self, stream_type: StreamType) -> Option<SourceInfo> {
+        match stream_type {
+            StreamType::Main => Some(self.source_main_status.info()),
+            StreamType::Sub => self.source_sub_status.as_ref().map(SourceStatus::info),
+        }
+    }
+
     // SendEvent sends event to recorder.
     /*fn SendEvent(&self, event: Event) {
         _ = self.send_event_tx.send(event)
@@ -188,6 +201,8 @@ enum MonitorManagerRequest {
     MonitorConfigs(oneshot::Sender<MonitorConfigs>),
     Stop(oneshot::Sender<()>),
     MonitorIsRunning((oneshot::Sender<bool>, MonitorId)),
+    MonitorSourceInfo((oneshot::Sender<Option<SourceInfo>>, MonitorId, StreamType)),
+    SetRecordingAll((oneshot::Sender<()>, bool)),
 }
 
 #[derive(Clone)]
@@ -240,6 +255,7 @@ impl MonitorManager {
                 hls_server,
                 path: config_path,
                 hooks: None,
+                recording_enabled: watch::channel(true).0,
             }
             .run(rx)
             .await;
@@ -350,6 +366,37 @@ impl MonitorManager {
 
         rx.await.expect("actor should respond")
     }
+
+    // Returns None if the monitor isn't running or the stream doesn't exist.
+    pub async fn monitor_source_info(
+        &self,
+        monitor_id: MonitorId,
+        stream_type: StreamType,
+    ) -> Option<SourceInfo> {
+        let (tx, rx) = oneshot::channel();
+        self.0
+            .send(MonitorManagerRequest::MonitorSourceInfo((
+                tx,
+                monitor_id,
+                stream_type,
+            )))
+            .await
+            .expect("actor should still be active");
+
+        rx.await.expect("actor should respond")
+    }
+
+    // Pauses or resumes recording on all monitors. Active
+    // recordings are saved when recording is paused.
+    pub async fn set_recording_all(&self, enable: bool) {
+        let (tx, rx) = oneshot::channel();
+        self.0
+            .send(MonitorManagerRequest::SetRecordingAll((tx, enable)))
+            .await
+            .expect("actor should still be active");
+
+        rx.await.expect("actor should respond");
+    }
 }
 
 struct MonitorManagerState {
@@ -364,6 +411,9 @@ struct MonitorManagerState {
     path: PathBuf,
 
     hooks: Option<DynMonitorHooks>,
+
+    // Recording is paused on all monitors if false.
+    recording_enabled: watch::Sender<bool>,
 }
 
 impl MonitorManagerState {
@@ -415,6 +465,17 @@ impl MonitorManagerState {
                     res.send(self.started_monitors.get(&monitor_id).is_some())
                         .expect("caller should receive response");
                 }
+                MonitorManagerRequest::MonitorSourceInfo((res, monitor_id, stream_type)) => {
+                    let info = self
+                        .started_monitors
+                        .get(&monitor_id)
+                        .and_then(|m| m.source_info(stream_type));
+                    res.send(info).expect("caller should receive response");
+                }
+                MonitorManagerRequest::SetRecordingAll((res, enable)) => {
+                    self.set_recording_all(enable);
+                    res.send(()).expect("caller should receive response");
+                }
             }
         }
     }
@@ -537,12 +598,34 @@ impl MonitorManagerState {
                     name: c.name().to_owned(),
                     enable: c.enabled(),
                     has_sub_stream: c.has_sub_stream(),
+                    source: self
+                        .started_monitors
+                        .get(c.id())
+                        .and_then(|m| m.source_info(StreamType::Main)),
                 },
             );
         }
         configs
     }
 
+    fn set_recording_all(&self, enable: bool) {
+        let prev = self.recording_enabled.send_replace(enable);
+        if prev == enable {
+            return;
+        }
+        let msg = if enable {
+            "resuming recording on all monitors"
+        } else {
+            "pausing recording on all monitors"
+        };
+        self.logger.log(LogEntry::new(
+            LogLevel::Info,
+            "monitor",
+            None,
+            msg.to_owned(),
+        ));
+    }
+
     fn config_path(&self, id: &MonitorId) -> PathBuf {
         fn monitor_config_path(path: &Path, id: String) -> PathBuf {
             path.join(id + ".json")
@@ -598,6 +681,7 @@ impl MonitorManagerState {
             source_main.clone(),
             config.clone(),
             self.rec_db.clone(),
+            self.recording_enabled.subscribe(),
         );
 
         let (source_main_tx, mut source_main_rx) = mpsc::channel(1);
@@ -609,6 +693,8 @@ impl MonitorManagerState {
             shutdown_complete: Mutex::new(shutdown_complete_rx),
             source_main_tx,
             source_sub_tx,
+            source_main_status: source_main.status().clone(),
+            source_sub_status: source_sub.as_ref().map(|s| s.status().clone()),
             send_event_tx,
         });
 
@@ -662,6 +748,9 @@ pub struct MonitorInfo {
 
     #[serde(rename = "hasSubStream")]
     has_sub_stream: bool,
+
+    // Main stream status, None if the monitor isn't running.
+    source: Option<SourceInfo>,
 }
 
 pub type DynMonitorHooks = Arc<dyn MonitorHooks + Send + Sync>;
@@ -679,7 +768,9 @@ mod tests {
     use super::*;
     use bytesize::ByteSize;
     use common::{
-        monitor::{Config, Protocol, SelectedSource, SourceConfig, SourceRtspConfig},
+        monitor::{
+            Config, MinResolutionPolicy, Protocol, SelectedSource, SourceConfig, SourceRtspConfig,
+        },
         DummyLogger, ParseMonitorIdError,
     };
     use pretty_assertions::assert_eq;
@@ -824,11 +915,16 @@ mod tests {
                 source: SelectedSource::Rtsp,
                 always_record: false,
                 video_length: 0.0,
+                split_at_midnight: false,
             },
             SourceConfig::Rtsp(SourceRtspConfig {
                 protocol: Protocol::Tcp,
                 main_stream: "rtsp://x1".parse().unwrap(),
                 sub_stream: None,
+                stall_timeout: 0,
+                min_width: 0,
+                min_height: 0,
+                min_resolution_policy: MinResolutionPolicy::Reject,
             }),
             json!({
                 "id": "new",
@@ -876,11 +972,16 @@ mod tests {
                 source: SelectedSource::Rtsp,
                 always_record: false,
                 video_length: 0.0,
+                split_at_midnight: false,
             },
             SourceConfig::Rtsp(SourceRtspConfig {
                 protocol: Protocol::Tcp,
                 main_stream: "rtsp://x1".parse().unwrap(),
                 sub_stream: None,
+                stall_timeout: 0,
+                min_width: 0,
+                min_height: 0,
+                min_resolution_policy: MinResolutionPolicy::Reject,
             }),
             json!({
                 "id": "1",
@@ -1007,11 +1108,16 @@ mod tests {
                         source: SelectedSource::Rtsp,
                         always_record: false,
                         video_length: 0.0,
+                        split_at_midnight: false,
                     },
                     SourceConfig::Rtsp(SourceRtspConfig {
                         protocol: Protocol::Tcp,
                         main_stream: "rtsp://x1".parse().unwrap(),
                         sub_stream: None,
+                        stall_timeout: 0,
+                        min_width: 0,
+                        min_height: 0,
+                        min_resolution_policy: MinResolutionPolicy::Reject,
                     }),
                     json!({
                         "id": "1",
@@ -1037,11 +1143,16 @@ mod tests {
                         source: SelectedSource::Rtsp,
                         always_record: false,
                         video_length: 0.0,
+                        split_at_midnight: false,
                     },
                     SourceConfig::Rtsp(SourceRtspConfig {
                         protocol: Protocol::Udp,
                         main_stream: "rtsp://x1".parse().unwrap(),
                         sub_stream: Some("rtsp://x2".parse().unwrap()),
+                        stall_timeout: 0,
+                        min_width: 0,
+                        min_height: 0,
+                        min_resolution_policy: MinResolutionPolicy::Reject,
                     }),
                     json!({
                         "id": "2",
@@ -1071,4 +1182,21 @@ mod tests {
             Err(MonitorRestartError::NotExist(_))
         ));
     }
+
+    #[tokio::test]
+    async fn test_monitor_source_info_not_running() {
+        let (_, _, manager) = new_test_manager();
+        assert_eq!(
+            None,
+            manager
+                .monitor_source_info(m_id("1"), StreamType::Main)
+                .await
+        );
+        assert_eq!(
+            None,
+            manager
+                .monitor_source_info(m_id("x"), StreamType::Sub)
+                .await
+        );
+    }
 }
@@ -23,7 +23,7 @@ use std::{pin::Pin, sync::Arc, task::Poll};
 use thiserror::Error;
 use tokio::{
     io::{AsyncWriteExt, BufWriter},
-    sync::{mpsc, Mutex},
+    sync::{mpsc, watch, Mutex},
     time::sleep,
 };
 use tokio_util::sync::CancellationToken;
@@ -38,6 +38,7 @@ pub fn new_recorder(
     source_main: Arc<Source>,
     config: MonitorConfig,
     rec_db: Arc<RecDb>,
+    mut recording_enabled: watch::Receiver<bool>,
 ) -> mpsc::Sender<Event> {
     let (send_event_tx, mut send_event_rx) = mpsc::channel::<Event>(1);
     let c = RecordingContext {
@@ -55,7 +56,7 @@ pub fn new_recorder(
         let shutdown_complete = shutdown_complete;
 
         let mut recording_session: Option<RecordingSession> = None;
-        if c.config.always_record() {
+        if c.config.always_record() && *recording_enabled.borrow() {
             c.log(LogLevel::Debug, "always record");
             recording_session = Some(RecordingSession::new(
                 &token,
@@ -112,6 +113,43 @@ pub fn new_recorder(
                         c.log(LogLevel::Debug, "session stopped2");
                         recording_session = None;
                     }
+
+                    Ok(()) = recording_enabled.changed() => {
+                        if *recording_enabled.borrow() {
+                            continue
+                        }
+                        c.log(LogLevel::Info, "recording paused");
+                        session.token.cancel();
+
+                        // Wait for the current recording to be saved.
+                        session.on_exit_rx.recv().await;
+                        c.log(LogLevel::Debug, "session stopped");
+                        recording_session = None;
+                    }
+                }
+            } else if !*recording_enabled.borrow() {
+                tokio::select! {
+                    () = token.cancelled() => return,
+                    // Events are discarded while recording is paused.
+                    event = send_event_rx.recv() => {
+                        if event.is_none() {
+                            return
+                        }
+                    }
+                    Ok(()) = recording_enabled.changed() => {
+                        if !*recording_enabled.borrow() {
+                            continue
+                        }
+                        c.log(LogLevel::Info, "recording resumed");
+                        if c.config.always_record() {
+                            recording_session = Some(RecordingSession::new(
+                                &token,
+                                None,
+                                c.clone(),
+                                shutdown_complete.clone(),
+                            ));
+                        }
+                    }
                 }
             } else {
                 tokio::select! {
@@ -254,6 +292,9 @@ enum RunRecordingError {
 
     #[error("save recording: {0}")]
     SaveRecording(#[from] SaveRecordingError),
+
+    #[error("next midnight")]
+    NextMidnight,
 }
 
 #[derive(Clone)]
@@ -289,6 +330,15 @@ async fn run_recording(
 
     let start_time = first_segment.start_time();
 
+    let midnight = if c.config.split_at_midnight() {
+        let midnight = UnixNano::from(start_time)
+            .next_local_midnight()
+            .ok_or(RunRecordingError::NextMidnight)?;
+        Some(UnixH264::from(midnight))
+    } else {
+        None
+    };
+
     let monitor_id = c.config.id().to_owned();
     let recording = c
         .rec_db
@@ -327,6 +377,7 @@ async fn run_recording(
         first_segment,
         params,
         video_length,
+        midnight,
     )
     .await?;
     *c.prev_seg.lock().await = Some(new_prev_seg);
@@ -374,6 +425,7 @@ async fn generate_video(
     first_segment: Arc<SegmentFinalized>,
     params: &TrackParameters,
     max_duration: DurationH264,
+    midnight: Option<UnixH264>,
 ) -> Result<(Arc<SegmentFinalized>, UnixH264), GenerateVideoError> {
     use GenerateVideoError::*;
 
@@ -420,6 +472,13 @@ async fn generate_video(
             return Err(SkippedSegment(seg.id(), prev_seg.id() + 1));
         }
 
+        // The first segment of the new day starts the next recording.
+        if let Some(midnight) = midnight {
+            if !midnight.after(seg.start_time()) {
+                return Ok((prev_seg, end_time));
+            }
+        }
+
         prev_seg = seg.clone();
         w.write_parts(seg.parts()).await?;
         end_time = seg
@@ -680,16 +739,19 @@ mod tests {
     use std::{num::NonZeroU32, path::Path};
 
     use super::*;
+    use crate::{source::SourceStatus, Monitor, MonitorHooks};
+    use async_trait::async_trait;
     use bytesize::ByteSize;
     use common::{
         new_dummy_msg_logger,
-        time::{Duration, MINUTE},
-        Detection, DummyLogger, PointNormalized, RectangleNormalized, Region,
+        time::{Duration, H264_SECOND, MINUTE},
+        Detection, DummyLogger, HlsMuxer, PointNormalized, RectangleNormalized, Region, StreamType,
     };
     use pretty_assertions::assert_eq;
     use recdb::Disk;
+    use serde_json::json;
     use tempfile::tempdir;
-    use tokio::io::AsyncReadExt;
+    use tokio::{io::AsyncReadExt, sync::oneshot};
     /*
     func newTestRecorder(t *testing.T) *Recorder {
         t.Helper()
@@ -1085,4 +1147,203 @@ mod tests {
 }";
         assert_eq!(want, got);
     }
+
+    // One second segment.
+    fn test_segment(id: u64) -> Arc<SegmentFinalized> {
+        Arc::new(SegmentFinalized::new(
+            id,
+            0,
+            UnixH264::new(i64::try_from(id).unwrap() * H264_SECOND),
+            String::new(),
+            Vec::new(),
+            DurationH264::new(H264_SECOND),
+        ))
+    }
+
+    fn test_params() -> TrackParameters {
+        TrackParameters {
+            width: 64,
+            height: 64,
+            codec: String::new(),
+            extra_data: Vec::new(),
+        }
+    }
+
+    struct FakeMuxer {
+        params: TrackParameters,
+        segments: Vec<Arc<SegmentFinalized>>,
+    }
+
+    #[async_trait]
+    impl HlsMuxer for FakeMuxer {
+        fn params(&self) -> &TrackParameters {
+            &self.params
+        }
+
+        async fn next_segment(
+            &self,
+            prev_seg: Option<&SegmentFinalized>,
+        ) -> Option<Arc<SegmentFinalized>> {
+            let id = prev_seg.map_or(0, |v| v.id() + 1);
+            self.segments.iter().find(|v| v.id() == id).cloned()
+        }
+    }
+
+    #[tokio::test]
+    async fn test_generate_video_split_at_midnight() {
+        // Midnight is at the start of the fourth segment.
+        let muxer: DynHlsMuxer = Arc::new(FakeMuxer {
+            params: test_params(),
+            segments: (0..6).map(test_segment).collect(),
+        });
+        let midnight = UnixH264::new(3 * H264_SECOND);
+
+        let tempdir = tempdir().unwrap();
+        let rec_db = new_test_recdb(&tempdir.path().join("recordings"));
+        let recording = rec_db.test_recording().await;
+
+        let first_segment = muxer.next_segment(None).await.unwrap();
+        let (prev_seg, end_time) = generate_video(
+            CancellationToken::new(),
+            &recording,
+            &muxer,
+            first_segment,
+            muxer.params(),
+            DurationH264::new(60 * H264_SECOND),
+            Some(midnight),
+        )
+        .await
+        .unwrap();
+        assert_eq!(2, prev_seg.id());
+        assert_eq!(midnight, end_time);
+
+        let next = muxer.next_segment(Some(&prev_seg)).await.unwrap();
+        assert_eq!(3, next.id());
+        assert_eq!(midnight, next.start_time());
+    }
+
+    // Produces a new segment every 10 milliseconds.
+    struct LiveMuxer(TrackParameters);
+
+    #[async_trait]
+    impl HlsMuxer for LiveMuxer {
+        fn params(&self) -> &TrackParameters {
+            &self.0
+        }
+
+        async fn next_segment(
+            &self,
+            prev_seg: Option<&SegmentFinalized>,
+        ) -> Option<Arc<SegmentFinalized>> {
+            sleep(std::time::Duration::from_millis(10)).await;
+            Some(test_segment(prev_seg.map_or(0, |v| v.id() + 1)))
+        }
+    }
+
+    fn new_test_source(muxer: DynHlsMuxer) -> Arc<Source> {
+        let (get_muxer_tx, mut get_muxer_rx) = mpsc::channel::<oneshot::Sender<DynHlsMuxer>>(1);
+        let (subscribe_tx, _) = mpsc::channel(1);
+        tokio::spawn(async move {
+            while let Some(res) = get_muxer_rx.recv().await {
+                _ = res.send(muxer.clone());
+            }
+        });
+        Arc::new(Source::new(
+            StreamType::Main,
+            SourceStatus::new(1),
+            get_muxer_tx,
+            subscribe_tx,
+        ))
+    }
+
+    struct StubHooks;
+
+    #[async_trait]
+    impl MonitorHooks for StubHooks {
+        async fn on_monitor_start(&self, _: CancellationToken, _: Arc<Monitor>) {}
+        fn on_thumb_save(&self, _: &MonitorConfig, frame: Frame) -> Frame {
+            frame
+        }
+    }
+
+    fn count_files(dir: &Path, ext: &str) -> usize {
+        let mut count = 0;
+        for entry in std::fs::read_dir(dir).unwrap() {
+            let path = entry.unwrap().path();
+            if path.is_dir() {
+                count += count_files(&path, ext);
+            } else if path.extension().is_some_and(|v| v == ext) {
+                count += 1;
+            }
+        }
+        count
+    }
+
+    async fn wait_for_files(dir: &Path, ext: &str, want: usize) {
+        let result = tokio::time::timeout(std::time::Duration::from_secs(5), async {
+            while count_files(dir, ext) != want {
+                sleep(std::time::Duration::from_millis(10)).await;
+            }
+        })
+        .await;
+        assert!(result.is_ok(), "want {want} {ext} files");
+    }
+
+    #[tokio::test]
+    async fn test_set_recording_all() {
+        let tempdir = tempdir().unwrap();
+        let recordings_dir = tempdir.path().join("recordings");
+        std::fs::create_dir_all(&recordings_dir).unwrap();
+        let rec_db = Arc::new(new_test_recdb(&recordings_dir));
+        let token = CancellationToken::new();
+        let (shutdown_complete_tx, mut shutdown_complete_rx) = mpsc::channel(1);
+        let (recording_enabled_tx, _) = watch::channel(true);
+
+        for id in ["1", "2"] {
+            let config: MonitorConfig = serde_json::from_value(json!({
+                "id": id,
+                "name": id,
+                "enable": true,
+                "source": "rtsp",
+                "sourcertsp": {
+                    "protocol": "tcp",
+                    "mainStream": "rtsp://x",
+                },
+                "alwaysRecord": true,
+                "videoLength": 60.0,
+            }))
+            .unwrap();
+            new_recorder(
+                token.child_token(),
+                shutdown_complete_tx.clone(),
+                Arc::new(StubHooks),
+                DummyLogger::new(),
+                id.to_owned().try_into().unwrap(),
+                new_test_source(Arc::new(LiveMuxer(test_params()))),
+                config,
+                rec_db.clone(),
+                recording_enabled_tx.subscribe(),
+            );
+        }
+        drop(shutdown_complete_tx);
+
+        // Both monitors start recording.
+        wait_for_files(&recordings_dir, "meta", 2).await;
+        assert_eq!(0, count_files(&recordings_dir, "json"));
+
+        // Pausing saves the active recordings.
+        recording_enabled_tx.send_replace(false);
+        wait_for_files(&recordings_dir, "json", 2).await;
+
+        // No new recordings are started while paused.
+        sleep(std::time::Duration::from_millis(100)).await;
+        assert_eq!(2, count_files(&recordings_dir, "meta"));
+
+        // Resume.
+        recording_enabled_tx.send_replace(true);
+        wait_for_files(&recordings_dir, "meta", 4).await;
+
+        token.cancel();
+        shutdown_complete_rx.recv().await;
+    }
 }
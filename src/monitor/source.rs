@@ -2,13 +2,13 @@
 
 use crate::log_monitor;
 use common::{
-    monitor::{Protocol, RtspUrl, SourceRtspConfig},
+    monitor::{MinResolutionPolicy, Protocol, RtspUrl, SourceRtspConfig},
     recording::{FrameRateLimiter, FrameRateLimiterError},
     time::{DtsOffset, UnixH264},
     DynHlsMuxer, DynLogger, DynMsgLogger, H264Data, LogEntry, LogLevel, MonitorId, MsgLogger,
-    StreamType,
+    StreamType, TrackParameters,
 };
-use futures::StreamExt;
+use futures::{Stream, StreamExt};
 use hls::{
     track_params_from_video_params, CreateSegmenterError, H264Writer, HlsServer, ParseParamsError,
     SegmenterWriteH264Error,
@@ -22,11 +22,13 @@ use sentryshot_ffmpeg_h264::{
     H264BuilderError, H264Decoder, H264DecoderBuilder, Packet, PaddedBytes, Ready,
     ReceiveFrameError, SendPacketError,
 };
-use std::sync::Arc;
+use serde::Serialize;
+use std::{pin::Pin, sync::Arc};
 use thiserror::Error;
 use tokio::{
     runtime::Handle,
     sync::{broadcast, mpsc, oneshot},
+    time::{Instant, Sleep},
 };
 use tokio_util::sync::CancellationToken;
 use url::Url;
@@ -37,6 +39,7 @@ pub type FeedDecoded = mpsc::Receiver<Result<Frame, DecoderError>>;
 
 pub struct Source {
     stream_type: StreamType,
+    status: SourceStatus,
     get_muxer_tx: mpsc::Sender<oneshot::Sender<DynHlsMuxer>>,
     subscribe_tx: mpsc::Sender<oneshot::Sender<Feed>>,
 }
@@ -45,11 +48,13 @@ impl Source {
     #[must_use]
     pub fn new(
         stream_type: StreamType,
+        status: SourceStatus,
         get_muxer_tx: mpsc::Sender<oneshot::Sender<DynHlsMuxer>>,
         subscribe_tx: mpsc::Sender<oneshot::Sender<Feed>>,
     ) -> Self {
         Self {
             stream_type,
+            status,
             get_muxer_tx,
             subscribe_tx,
         }
@@ -60,6 +65,11 @@ impl Source {
         &self.stream_type
     }
 
+    #[must_use]
+    pub fn status(&self) -> &SourceStatus {
+        &self.status
+    }
+
     // Returns the HLS muxer for this source. Will block until the source has started.
     // Returns None if cancelled.
     pub async fn muxer(&self) -> Option<DynHlsMuxer> {
@@ -161,6 +171,7 @@ pub struct SourceRtsp {
     monitor_id: MonitorId,
     config: SourceRtspConfig,
     stream_type: StreamType,
+    status: SourceStatus,
 }
 
 impl SourceRtsp {
@@ -186,12 +197,14 @@ impl SourceRtsp {
             stream_type,
         ));
 
+        let status = SourceStatus::new(BREAKER_THRESHOLD);
         let source = Self {
             msg_logger,
             hls_server,
             monitor_id,
             config,
             stream_type,
+            status: status.clone(),
         };
 
         let (started_tx, mut started_rx) = mpsc::channel(1);
@@ -206,14 +219,69 @@ impl SourceRtsp {
                     return;
                 }
 
-                match source.run(token2.child_token(), started_tx.clone()).await {
-                    Ok(()) => source.log(LogLevel::Debug, "cancelled"),
-                    Err(e) => source.log(LogLevel::Error, &format!("crashed: {e}")),
+                if source.status.attempt() == BreakerState::HalfOpen {
+                    source.log(LogLevel::Info, "retrying after cooldown");
+                }
+
+                // Relay the start notification to close the breaker.
+                let (run_started_tx, mut run_started_rx) = mpsc::channel(1);
+                let run = source.run(token2.child_token(), run_started_tx);
+                tokio::pin!(run);
+                let result = loop {
+                    tokio::select! {
+                        res = &mut run => break res,
+                        Some(started) = run_started_rx.recv() => {
+                            source.status.success();
+                            _ = started_tx.send(started).await;
+                        }
+                    }
+                };
+                // The source may have started and crashed in the same poll.
+                if let Ok(started) = run_started_rx.try_recv() {
+                    source.status.success();
+                    _ = started_tx.send(started).await;
+                }
+
+                let retry_delay = match result {
+                    // Retrying won't help, wait for the monitor to be restarted.
+                    Err(e) if e.is_permanent() => {
+                        source.log(
+                            LogLevel::Error,
+                            &format!("{e}, not retrying until the monitor is restarted"),
+                        );
+                        token2.cancelled().await;
+                        continue;
+                    }
+                    Ok(()) => {
+                        source.log(LogLevel::Debug, "cancelled");
+                        RETRY_DELAY
+                    }
+                    // Not counted as a failure, the source did connect.
+                    Err(e @ SourceRtspRunError::Stalled(_)) => {
+                        source.log(LogLevel::Warning, &format!("{e}, restarting"));
+                        RETRY_DELAY
+                    }
+                    Err(e) => {
+                        source.log(LogLevel::Error, &format!("crashed: {e}"));
+                        let delay = source.status.failure();
+                        let info = source.status.info();
+                        if info.breaker == BreakerState::Open {
+                            source.log(
+                                LogLevel::Warning,
+                                &format!(
+                                    "failed {} times in a row, pausing retries for {}s",
+                                    info.failures,
+                                    delay.as_secs()
+                                ),
+                            );
+                        }
+                        delay
+                    }
                 };
 
                 tokio::select! {
                     () = token2.cancelled() => {}
-                    () = tokio::time::sleep(tokio::time::Duration::from_secs(10)) => {}
+                    () = tokio::time::sleep(retry_delay) => {}
                 }
             }
         });
@@ -268,7 +336,7 @@ impl SourceRtsp {
             }
         });
 
-        Some(Source::new(stream_type, get_muxer_tx, subscribe_tx))
+        Some(Source::new(stream_type, status, get_muxer_tx, subscribe_tx))
     }
 
     fn log(&self, level: LogLevel, msg: &str) {
@@ -349,18 +417,25 @@ impl SourceRtsp {
         // Buffer 10 frame to reduce dropped frames.
         let (feed_tx, _) = broadcast::channel(10);
 
+        // Restart the stream if the camera stops sending frames.
+        let mut stall_timer = StallTimer::new(self.config.stall_timeout(), self.status.clone());
+
         let mut hls_writer: Option<H264Writer> = None;
         loop {
             tokio::select! {
                 () = token.cancelled() => {
                     return Ok(());
                 },
-                pkt = session.next() => {
-                    let Some(pkt) = pkt else {
+                pkt = stall_timer.next(&mut session) => {
+                    let Some(pkt) = pkt? else {
                         return Err(Eof);
                     };
                     match pkt {
                         Ok(retina::codec::CodecItem::VideoFrame(frame)) => {
+                            stall_timer.reset();
+                            if frame.is_random_access_point() {
+                                self.status.keyframe();
+                            }
                             if let Some(hls_writer) = &mut hls_writer {
                                 let data = frame_to_sample(frame);
                                 hls_writer.write_h264(data.clone()).await?;
@@ -374,10 +449,21 @@ impl SourceRtsp {
 
                                 let stream = &session.streams()[frame.stream_id()];
                                 if let Some(ParametersRef::Video(params)) = stream.parameters() {
+                                    let params = track_params_from_video_params(params)?;
+                                    if self.stream_type.is_main() {
+                                        if let Err(e) = check_min_resolution(&self.config, &params) {
+                                            match self.config.min_resolution_policy {
+                                                MinResolutionPolicy::Warn => {
+                                                    self.log(LogLevel::Warning, &e.to_string());
+                                                }
+                                                MinResolutionPolicy::Reject => return Err(e),
+                                            }
+                                        }
+                                    }
                                     let result = self.hls_server.new_muxer(
                                         token.clone(),
                                         self.hls_name(),
-                                        track_params_from_video_params(params)?,
+                                        params,
                                         frame_to_sample(frame),
                                     ).await?;
                                     let Some((muxer, hls_writer2)) = result else {
@@ -399,11 +485,7 @@ impl SourceRtsp {
     }
 
     fn hls_name(&self) -> String {
-        if self.stream_type.is_main() {
-            self.monitor_id.to_string()
-        } else {
-            self.monitor_id.to_string() + "_sub"
-        }
+        self.stream_type.hls_name(&self.monitor_id)
     }
 
     fn stream_url(&self) -> &RtspUrl {
@@ -418,6 +500,215 @@ impl SourceRtsp {
     }
 }
 
+// Detects when a stream stops producing frames. The source is
+// marked as stalled until the timer is reset by the next frame.
+struct StallTimer {
+    timeout: Option<std::time::Duration>,
+    sleep: Pin<Box<Sleep>>,
+    status: SourceStatus,
+
+    // A previous session may have stalled.
+    clear_stalled: bool,
+}
+
+#[derive(Debug, Error, PartialEq, Eq)]
+#[error("stalled: no frames received in {0:?}")]
+struct StalledError(std::time::Duration);
+
+impl StallTimer {
+    // Stall detection is disabled if `timeout` is None.
+    fn new(timeout: Option<std::time::Duration>, status: SourceStatus) -> Self {
+        Self {
+            timeout,
+            sleep: Box::pin(tokio::time::sleep(timeout.unwrap_or_default())),
+            status,
+            clear_stalled: true,
+        }
+    }
+
+    // Restarts the timer, should be called on every frame.
+    fn reset(&mut self) {
+        if self.clear_stalled {
+            self.status.set_stalled(false);
+            self.clear_stalled = false;
+        }
+        if let Some(timeout) = self.timeout {
+            self.sleep.as_mut().reset(Instant::now() + timeout);
+        }
+    }
+
+    // Returns the next item from the stream or an error
+    // if the timer expires before the item is received.
+    async fn next<S: Stream + Unpin>(
+        &mut self,
+        stream: &mut S,
+    ) -> Result<Option<S::Item>, StalledError> {
+        let Some(timeout) = self.timeout else {
+            return Ok(stream.next().await);
+        };
+        tokio::select! {
+            () = &mut self.sleep => {
+                self.status.set_stalled(true);
+                self.clear_stalled = true;
+                Err(StalledError(timeout))
+            }
+            item = stream.next() => Ok(item),
+        }
+    }
+}
+
+// Delay between restarts of a crashed source.
+const RETRY_DELAY: std::time::Duration = std::time::Duration::from_secs(10);
+
+// Consecutive failures before retries are paused.
+const BREAKER_THRESHOLD: u32 = 6;
+
+// Time retries are paused for once the breaker opens.
+const BREAKER_COOLDOWN: std::time::Duration = std::time::Duration::from_secs(60);
+
+// Circuit breaker for the source restart loop. After `threshold` consecutive
+// failures the breaker opens and retries are paused for `BREAKER_COOLDOWN`.
+// The next attempt is a trial, if it fails the breaker opens again.
+// The breaker closes once the source has started.
+#[derive(Debug)]
+struct CircuitBreaker {
+    threshold: u32,
+    failures: u32,
+    state: BreakerState,
+}
+
+#[derive(Clone, Copy, Debug, Serialize, PartialEq, Eq)]
+#[serde(rename_all = "camelCase")]
+pub enum BreakerState {
+    Closed,
+    Open,
+    HalfOpen,
+}
+
+impl CircuitBreaker {
+    fn new(threshold: u32) -> Self {
+        Self {
+            threshold,
+            failures: 0,
+            state: BreakerState::Closed,
+        }
+    }
+
+    fn state(&self) -> BreakerState {
+        self.state
+    }
+
+    fn failures(&self) -> u32 {
+        self.failures
+    }
+
+    // Should be called before each attempt, returns the new state.
+    fn attempt(&mut self) -> BreakerState {
+        if self.state == BreakerState::Open {
+            self.state = BreakerState::HalfOpen;
+        }
+        self.state
+    }
+
+    fn success(&mut self) {
+        self.failures = 0;
+        self.state = BreakerState::Closed;
+    }
+
+    // Records a failed attempt and returns the delay before the next one.
+    fn failure(&mut self) -> std::time::Duration {
+        self.failures = self.failures.saturating_add(1);
+        if self.state == BreakerState::HalfOpen || self.failures >= self.threshold {
+            self.state = BreakerState::Open;
+            return BREAKER_COOLDOWN;
+        }
+        RETRY_DELAY
+    }
+}
+
+// Health of a source, shared between the source tasks.
+#[derive(Clone, Debug)]
+pub struct SourceStatus(Arc<std::sync::Mutex<SourceState>>);
+
+#[derive(Debug)]
+struct SourceState {
+    breaker: CircuitBreaker,
+    stalled: bool,
+    last_keyframe: Option<Instant>,
+}
+
+impl SourceStatus {
+    pub(crate) fn new(threshold: u32) -> Self {
+        Self(Arc::new(std::sync::Mutex::new(SourceState {
+            breaker: CircuitBreaker::new(threshold),
+            stalled: false,
+            last_keyframe: None,
+        })))
+    }
+
+    fn attempt(&self) -> BreakerState {
+        self.0.lock().expect("not poisoned").breaker.attempt()
+    }
+
+    fn success(&self) {
+        self.0.lock().expect("not poisoned").breaker.success();
+    }
+
+    fn failure(&self) -> std::time::Duration {
+        self.0.lock().expect("not poisoned").breaker.failure()
+    }
+
+    fn set_stalled(&self, stalled: bool) {
+        self.0.lock().expect("not poisoned").stalled = stalled;
+    }
+
+    // Records that a keyframe was received.
+    fn keyframe(&self) {
+        self.0.lock().expect("not poisoned").last_keyframe = Some(Instant::now());
+    }
+
+    #[must_use]
+    pub fn info(&self) -> SourceInfo {
+        let state = self.0.lock().expect("not poisoned");
+        SourceInfo {
+            breaker: state.breaker.state(),
+            failures: state.breaker.failures(),
+            stalled: state.stalled,
+            last_keyframe_age_ms: state
+                .last_keyframe
+                .map(|v| u64::try_from(v.elapsed().as_millis()).unwrap_or(u64::MAX)),
+        }
+    }
+}
+
+#[derive(Clone, Copy, Debug, Serialize, PartialEq, Eq)]
+pub struct SourceInfo {
+    breaker: BreakerState,
+    failures: u32,
+
+    // No frames received within the stall timeout.
+    stalled: bool,
+
+    // Milliseconds since the last keyframe, None if no keyframe has been received.
+    #[serde(rename = "lastKeyframeAgeMs")]
+    last_keyframe_age_ms: Option<u64>,
+}
+
+fn check_min_resolution(
+    config: &SourceRtspConfig,
+    params: &TrackParameters,
+) -> Result<(), SourceRtspRunError> {
+    if params.width < config.min_width || params.height < config.min_height {
+        return Err(SourceRtspRunError::ResolutionTooLow(
+            params.width,
+            params.height,
+            config.min_width,
+            config.min_height,
+        ));
+    }
+    Ok(())
+}
+
 #[allow(clippy::similar_names)]
 fn frame_to_sample(frame: VideoFrame) -> H264Data {
     let timestamp = frame.timestamp();
@@ -437,6 +728,12 @@ enum SourceRtspRunError {
     #[error("end of file")]
     Eof,
 
+    #[error("{0}")]
+    Stalled(#[from] StalledError),
+
+    #[error("resolution {0}x{1} is below the minimum {2}x{3}")]
+    ResolutionTooLow(u16, u16, u16, u16),
+
     #[error("describe: {0}")]
     Describe(retina::Error),
 
@@ -468,6 +765,14 @@ enum SourceRtspRunError {
     CreateSegmenter(#[from] CreateSegmenterError),
 }
 
+impl SourceRtspRunError {
+    // Reports whether the error is caused by the camera
+    // configuration and will not go away by retrying.
+    fn is_permanent(&self) -> bool {
+        matches!(self, Self::ResolutionTooLow(..))
+    }
+}
+
 #[derive(Debug, Error)]
 enum RemoveCreds {
     #[error("set password")]
@@ -618,3 +923,174 @@ fn new_decoder(
 
     frame_rx
 }
+
+#[allow(clippy::unwrap_used)]
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pretty_assertions::assert_eq;
+    use test_case::test_case;
+
+    #[test]
+    fn test_circuit_breaker() {
+        let mut breaker = CircuitBreaker::new(3);
+        assert_eq!(BreakerState::Closed, breaker.attempt());
+        assert_eq!(RETRY_DELAY, breaker.failure());
+        assert_eq!(BreakerState::Closed, breaker.attempt());
+        assert_eq!(RETRY_DELAY, breaker.failure());
+
+        // Open.
+        assert_eq!(BreakerState::Closed, breaker.attempt());
+        assert_eq!(BREAKER_COOLDOWN, breaker.failure());
+        assert_eq!(BreakerState::Open, breaker.state());
+        assert_eq!(3, breaker.failures());
+
+        // A failed trial opens the breaker again.
+        assert_eq!(BreakerState::HalfOpen, breaker.attempt());
+        assert_eq!(BREAKER_COOLDOWN, breaker.failure());
+        assert_eq!(BreakerState::Open, breaker.state());
+
+        // A successful trial closes it.
+        assert_eq!(BreakerState::HalfOpen, breaker.attempt());
+        breaker.success();
+        assert_eq!(BreakerState::Closed, breaker.state());
+        assert_eq!(0, breaker.failures());
+        assert_eq!(RETRY_DELAY, breaker.failure());
+    }
+
+    #[test]
+    fn test_source_status() {
+        let status = SourceStatus::new(2);
+        let want = SourceInfo {
+            breaker: BreakerState::Closed,
+            failures: 0,
+            stalled: false,
+            last_keyframe_age_ms: None,
+        };
+        assert_eq!(want, status.info());
+
+        status.attempt();
+        status.failure();
+        status.attempt();
+        status.failure();
+        let want = SourceInfo {
+            breaker: BreakerState::Open,
+            failures: 2,
+            stalled: false,
+            last_keyframe_age_ms: None,
+        };
+        assert_eq!(want, status.info());
+        assert_eq!(
+            r#"{"breaker":"open","failures":2,"stalled":false,"lastKeyframeAgeMs":null}"#,
+            serde_json::to_string(&status.info()).unwrap()
+        );
+
+        status.attempt();
+        assert_eq!(
+            r#"{"breaker":"halfOpen","failures":2,"stalled":false,"lastKeyframeAgeMs":null}"#,
+            serde_json::to_string(&status.info()).unwrap()
+        );
+    }
+
+    #[tokio::test]
+    async fn test_source_status_keyframe_age() {
+        tokio::time::pause();
+        let status = SourceStatus::new(2);
+        let age = || status.info().last_keyframe_age_ms;
+
+        // Keyframes keep arriving.
+        for _ in 0..3 {
+            status.keyframe();
+            assert_eq!(Some(0), age());
+            tokio::time::advance(std::time::Duration::from_secs(2)).await;
+            assert_eq!(Some(2000), age());
+        }
+
+        // Encoder hangs, the age keeps growing.
+        tokio::time::advance(std::time::Duration::from_secs(3)).await;
+        assert_eq!(Some(5000), age());
+        tokio::time::advance(std::time::Duration::from_secs(60)).await;
+        assert_eq!(Some(65000), age());
+    }
+
+    #[tokio::test]
+    async fn test_stall_timer() {
+        tokio::time::pause();
+        let (mut tx, mut rx) = futures::channel::mpsc::channel(1);
+        let status = SourceStatus::new(2);
+        let mut timer = StallTimer::new(Some(std::time::Duration::from_secs(10)), status.clone());
+
+        // Frames keep arriving, each one resets the timer.
+        for i in 0..3 {
+            tokio::time::advance(std::time::Duration::from_secs(6)).await;
+            tx.try_send(i).unwrap();
+            assert_eq!(Ok(Some(i)), timer.next(&mut rx).await);
+            timer.reset();
+            assert!(!status.info().stalled);
+        }
+
+        // Media stops.
+        let start = Instant::now();
+        assert_eq!(
+            Err(StalledError(std::time::Duration::from_secs(10))),
+            timer.next(&mut rx).await
+        );
+        // The timer has millisecond resolution.
+        let elapsed = start.elapsed().as_millis();
+        assert!((10_000..=10_001).contains(&elapsed), "{elapsed}");
+        assert!(status.info().stalled);
+
+        // The stalled state is kept by the next session until a frame is received.
+        let mut timer = StallTimer::new(Some(std::time::Duration::from_secs(10)), status.clone());
+        assert!(status.info().stalled);
+        tx.try_send(3).unwrap();
+        assert_eq!(Ok(Some(3)), timer.next(&mut rx).await);
+        timer.reset();
+        assert!(!status.info().stalled);
+    }
+
+    #[tokio::test]
+    async fn test_stall_timer_disabled() {
+        tokio::time::pause();
+        let (mut tx, mut rx) = futures::channel::mpsc::channel(1);
+        let mut timer = StallTimer::new(None, SourceStatus::new(2));
+
+        tokio::time::advance(std::time::Duration::from_secs(3600)).await;
+        tx.try_send(1).unwrap();
+        assert_eq!(Ok(Some(1)), timer.next(&mut rx).await);
+
+        drop(tx);
+        assert_eq!(Ok(None), timer.next(&mut rx).await);
+    }
+
+    #[test_case(640, 480, true; "equal")]
+    #[test_case(1920, 1080, true; "above")]
+    #[test_case(320, 480, false; "narrow")]
+    #[test_case(640, 240, false; "short")]
+    fn test_check_min_resolution(width: u16, height: u16, ok: bool) {
+        let config = SourceRtspConfig {
+            protocol: Protocol::Tcp,
+            main_stream: "rtsp://x".parse().unwrap(),
+            sub_stream: None,
+            stall_timeout: 0,
+            min_width: 640,
+            min_height: 480,
+            min_resolution_policy: MinResolutionPolicy::Reject,
+        };
+        let params = TrackParameters {
+            width,
+            height,
+            codec: String::new(),
+            extra_data: Vec::new(),
+        };
+        let result = check_min_resolution(&config, &params);
+        assert_eq!(ok, result.is_ok());
+        if let Err(e) = result {
+            assert!(e.is_permanent());
+            assert_eq!(
+                format!("resolution {width}x{height} is below the minimum 640x480"),
+                e.to_string()
+            );
+        }
+    }
+}
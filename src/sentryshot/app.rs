@@ -23,10 +23,11 @@ use plugin::{
 use recdb::{Disk, RecDb};
 use recording::VideoCache;
 use rust_embed::RustEmbed;
+use socket2::{Domain, Protocol, Socket, Type};
 use std::{
     collections::HashMap,
     ffi::OsStr,
-    net::{IpAddr, Ipv4Addr, SocketAddr},
+    net::{IpAddr, Ipv4Addr, Ipv6Addr, SocketAddr},
     path::{Path, PathBuf},
     sync::Arc,
 };
@@ -377,6 +378,46 @@ impl App {
                     )
                     .with_state(self.auth.clone()),
             )
+            // Pause recording on all monitors.
+            .route(
+                "/api/recording/pause",
+                post(recording_pause_handler)
+                    .with_state(self.monitor_manager.clone())
+                    .route_layer(
+                        ServiceBuilder::new()
+                            .layer(middleware::from_fn_with_state(self.auth.clone(), admin))
+                            .layer(middleware::from_fn_with_state(self.auth.clone(), csrf)),
+                    )
+                    .with_state(self.auth.clone()),
+            )
+            // Resume recording on all monitors.
+            .route(
+                "/api/recording/resume",
+                post(recording_resume_handler)
+                    .with_state(self.monitor_manager.clone())
+                    .route_layer(
+                        ServiceBuilder::new()
+                            .layer(middleware::from_fn_with_state(self.auth.clone(), admin))
+                            .layer(middleware::from_fn_with_state(self.auth.clone(), csrf)),
+                    )
+                    .with_state(self.auth.clone()),
+            )
+            // Monitor stream parameters.
+            .route(
+                "/api/monitor/params",
+                get(monitor_params_handler)
+                    .with_state(self.hls_server.clone())
+                    .route_layer(middleware::from_fn_with_state(self.auth.clone(), user))
+                    .with_state(self.auth.clone()),
+            )
+            // Monitor stream status.
+            .route(
+                "/api/monitor/status",
+                get(monitor_status_handler)
+                    .with_state(self.monitor_manager.clone())
+                    .route_layer(middleware::from_fn_with_state(self.auth.clone(), user))
+                    .with_state(self.auth.clone()),
+            )
             // Monitors.
             .route(
                 "/api/monitors",
@@ -451,13 +492,12 @@ impl App {
         });
 
         let (server_exited_tx, server_exited_rx) = oneshot::channel();
-        let addr = SocketAddr::new(IpAddr::V4(Ipv4Addr::new(0, 0, 0, 0)), self.env.port());
 
         tokio::spawn(start_server(
             self.token.child_token(),
             self.shutdown_complete_tx.clone(),
             server_exited_tx,
-            addr,
+            self.env.port(),
             self.router,
         ));
 
@@ -521,10 +561,10 @@ async fn start_server(
     token: CancellationToken,
     _shutdown_complete: mpsc::Sender<()>,
     on_exit: oneshot::Sender<Result<(), ServerError>>,
-    addr: SocketAddr,
+    port: u16,
     router: Router,
 ) {
-    let listener = match TcpListener::bind(addr).await {
+    let listener = match bind(port).await {
         Ok(v) => v,
         Err(e) => {
             let _ = on_exit.send(Err(ServerError::Bind(e)));
@@ -536,6 +576,39 @@ async fn start_server(
     let _ = on_exit.send(graceful.await.map_err(ServerError::Server));
 }
 
+// Listens on all IPv6 and IPv4 addresses. Falls back
+// to IPv4 only if IPv6 is unavailable on the host.
+async fn bind(port: u16) -> std::io::Result<TcpListener> {
+    match bind_dual_stack(port) {
+        Ok(v) => Ok(v),
+        Err(e) if ipv6_unavailable(&e) => {
+            let addr = SocketAddr::new(IpAddr::V4(Ipv4Addr::UNSPECIFIED), port);
+            TcpListener::bind(addr).await
+        }
+        Err(e) => Err(e),
+    }
+}
+
+// Binds an IPv6 socket that also accepts IPv4 connections.
+fn bind_dual_stack(port: u16) -> std::io::Result<TcpListener> {
+    let socket = Socket::new(Domain::IPV6, Type::STREAM, Some(Protocol::TCP))?;
+    socket.set_only_v6(false)?;
+    socket.set_reuse_address(true)?;
+    socket.set_nonblocking(true)?;
+    let addr = SocketAddr::new(IpAddr::V6(Ipv6Addr::UNSPECIFIED), port);
+    socket.bind(&addr.into())?;
+    socket.listen(1024)?;
+    TcpListener::from_std(socket.into())
+}
+
+// Reports whether the error means that IPv6 is disabled or unsupported.
+fn ipv6_unavailable(e: &std::io::Error) -> bool {
+    matches!(
+        e.raw_os_error(),
+        Some(libc::EAFNOSUPPORT | libc::EADDRNOTAVAIL)
+    )
+}
+
 // TimeZone returns system time zone location.
 fn time_zone() -> Option<String> {
     // Try 'TZ'.
@@ -596,3 +669,41 @@ fn time_zone() -> Option<String> {
     }
     zone.map(|v| v.to_string_lossy().to_string().trim().to_owned())
 }
+
+#[allow(clippy::unwrap_used)]
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pretty_assertions::assert_eq;
+    use test_case::test_case;
+    use tokio::net::TcpStream;
+
+    #[tokio::test]
+    async fn test_bind() {
+        let listener = bind(0).await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        let port = addr.port();
+
+        TcpStream::connect((Ipv4Addr::LOCALHOST, port))
+            .await
+            .unwrap();
+        if addr.is_ipv6() {
+            TcpStream::connect((Ipv6Addr::LOCALHOST, port))
+                .await
+                .unwrap();
+        }
+
+        // Other errors are not hidden by the fallback.
+        let err = bind(port).await.unwrap_err();
+        assert_eq!(std::io::ErrorKind::AddrInUse, err.kind());
+    }
+
+    #[test_case(libc::EAFNOSUPPORT, true; "af not supported")]
+    #[test_case(libc::EADDRNOTAVAIL, true; "addr not available")]
+    #[test_case(libc::EADDRINUSE, false; "addr in use")]
+    #[test_case(libc::EACCES, false; "access denied")]
+    fn test_ipv6_unavailable(code: i32, want: bool) {
+        let err = std::io::Error::from_raw_os_error(code);
+        assert_eq!(want, ipv6_unavailable(&err));
+    }
+}
@@ -938,6 +938,10 @@ function newSourceField(options, getField) {
  * @property {Field<string>} protocol
  * @property {Field<string>} mainStream
  * @property {Field<string>} subStream
+ * @property {Field<number>} stallTimeout
+ * @property {Field<number>} minWidth
+ * @property {Field<number>} minHeight
+ * @property {Field<string>} minResolutionPolicy
  */
 
 /** @returns {Field<string>} */
@@ -965,6 +969,14 @@ function newSourceRTSP() {
 				placeholder: "rtsp://x.x.x.x/sub (optional)",
 			}
 		),
+		stallTimeout: fieldTemplate.integer("Stall timeout (sec)", "0"),
+		minWidth: fieldTemplate.integer("Minimum width", "0"),
+		minHeight: fieldTemplate.integer("Minimum height", "0"),
+		minResolutionPolicy: fieldTemplate.select(
+			"Minimum resolution policy",
+			["reject", "warn"],
+			"reject"
+		),
 	};
 
 	const form = newForm(fields);
@@ -1075,6 +1087,7 @@ function init() {
 			"15",
 			"15"
 		);
+		monitorFields.splitAtMidnight = fieldTemplate.toggle("Split at midnight", false);
 		//timestampOffset: fieldTemplate.integer("Timestamp offset (ms)", "500", "500"),
 		/* SETTINGS_LAST_MONITOR_FIELD */
 